@@ -0,0 +1,303 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Package marshal converts Go values to and from noms types.Struct using
+// reflection, the way encoding/json converts Go values to and from JSON.
+// Struct fields may be annotated with a `noms:"name,omitempty"` tag to
+// rename the field, omit it from the output when it holds its zero value,
+// or skip it entirely with `noms:"-"`.
+package marshal
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/attic-labs/noms/go/types"
+)
+
+// Marshal converts a Go value to a noms types.Struct. v must be a struct, or
+// a pointer to one; slices become types.List, maps become types.Map, and
+// nested structs are marshaled recursively.
+func Marshal(v interface{}) (s types.Struct, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if me, ok := r.(*marshalError); ok {
+				err = me.err
+				return
+			}
+			panic(r)
+		}
+	}()
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return types.Struct{}, fmt.Errorf("marshal: nil pointer")
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return types.Struct{}, fmt.Errorf("marshal: expected struct, got %s", rv.Kind())
+	}
+
+	return marshalStruct(rv), nil
+}
+
+// Unmarshal converts a noms types.Struct into a Go value. v must be a
+// non-nil pointer to a struct.
+func Unmarshal(s types.Struct, v interface{}) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if me, ok := r.(*marshalError); ok {
+				err = me.err
+				return
+			}
+			panic(r)
+		}
+	}()
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("unmarshal: expected non-nil pointer, got %T", v)
+	}
+	if rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("unmarshal: expected pointer to struct, got %T", v)
+	}
+
+	unmarshalStruct(s, rv.Elem())
+	return nil
+}
+
+type marshalError struct {
+	err error
+}
+
+func fail(format string, args ...interface{}) {
+	panic(&marshalError{fmt.Errorf(format, args...)})
+}
+
+// fieldPlan describes how a single Go struct field maps onto a noms field,
+// derived once per Go type and cached so repeated marshaling doesn't pay for
+// reflection every time (mirrors encoding/json's typeCache).
+type fieldPlan struct {
+	name      string
+	index     []int
+	omitempty bool
+}
+
+var planCache sync.Map // map[reflect.Type][]fieldPlan
+
+func plansFor(t reflect.Type) []fieldPlan {
+	if cached, ok := planCache.Load(t); ok {
+		return cached.([]fieldPlan)
+	}
+
+	plans := buildPlans(t)
+	actual, _ := planCache.LoadOrStore(t, plans)
+	return actual.([]fieldPlan)
+}
+
+func buildPlans(t reflect.Type) []fieldPlan {
+	plans := make([]fieldPlan, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue // unexported
+		}
+
+		name, opts := parseTag(f)
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		name = types.EscapeStructField(name)
+
+		plans = append(plans, fieldPlan{
+			name:      name,
+			index:     f.Index,
+			omitempty: opts.Contains("omitempty"),
+		})
+	}
+	return plans
+}
+
+func parseTag(f reflect.StructField) (name string, opts tagOptions) {
+	tag := f.Tag.Get("noms")
+	if tag == "" {
+		return "", tagOptions("")
+	}
+	parts := strings.Split(tag, ",")
+	return parts[0], tagOptions(strings.Join(parts[1:], ","))
+}
+
+type tagOptions string
+
+func (o tagOptions) Contains(opt string) bool {
+	for _, s := range strings.Split(string(o), ",") {
+		if s == opt {
+			return true
+		}
+	}
+	return false
+}
+
+func marshalStruct(rv reflect.Value) types.Struct {
+	data := map[string]types.Value{}
+	for _, p := range plansFor(rv.Type()) {
+		fv := rv.FieldByIndex(p.index)
+		if p.omitempty && isEmptyValue(fv) {
+			continue
+		}
+		data[p.name] = marshalValue(fv)
+	}
+	return types.NewStruct(rv.Type().Name(), data)
+}
+
+func marshalValue(rv reflect.Value) types.Value {
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			fail("marshal: nil not supported for %s", rv.Type())
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Bool:
+		return types.Bool(rv.Bool())
+	case reflect.String:
+		return types.String(rv.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return types.Number(float64(rv.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return types.Number(float64(rv.Uint()))
+	case reflect.Float32, reflect.Float64:
+		return types.Number(rv.Float())
+	case reflect.Struct:
+		return marshalStruct(rv)
+	case reflect.Slice, reflect.Array:
+		values := make(types.ValueSlice, rv.Len())
+		for i := range values {
+			values[i] = marshalValue(rv.Index(i))
+		}
+		return types.NewList(values...)
+	case reflect.Map:
+		kv := make(types.ValueSlice, 0, rv.Len()*2)
+		for _, k := range rv.MapKeys() {
+			kv = append(kv, marshalValue(k), marshalValue(rv.MapIndex(k)))
+		}
+		return types.NewMap(kv...)
+	default:
+		fail("marshal: unsupported kind %s", rv.Kind())
+		return nil
+	}
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+func unmarshalStruct(s types.Struct, rv reflect.Value) {
+	if rv.Kind() != reflect.Struct {
+		fail("unmarshal: expected struct, got %s", rv.Kind())
+	}
+	for _, p := range plansFor(rv.Type()) {
+		v, ok := s.MaybeGet(p.name)
+		if !ok {
+			continue
+		}
+		unmarshalValue(v, rv.FieldByIndex(p.index))
+	}
+}
+
+func unmarshalValue(v types.Value, rv reflect.Value) {
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Bool:
+		b, ok := v.(types.Bool)
+		if !ok {
+			fail("unmarshal: expected types.Bool, got %T", v)
+		}
+		rv.SetBool(bool(b))
+	case reflect.String:
+		s, ok := v.(types.String)
+		if !ok {
+			fail("unmarshal: expected types.String, got %T", v)
+		}
+		rv.SetString(string(s))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := v.(types.Number)
+		if !ok {
+			fail("unmarshal: expected types.Number, got %T", v)
+		}
+		rv.SetInt(int64(n))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, ok := v.(types.Number)
+		if !ok {
+			fail("unmarshal: expected types.Number, got %T", v)
+		}
+		rv.SetUint(uint64(n))
+	case reflect.Float32, reflect.Float64:
+		n, ok := v.(types.Number)
+		if !ok {
+			fail("unmarshal: expected types.Number, got %T", v)
+		}
+		rv.SetFloat(float64(n))
+	case reflect.Struct:
+		st, ok := v.(types.Struct)
+		if !ok {
+			fail("unmarshal: expected types.Struct, got %T", v)
+		}
+		unmarshalStruct(st, rv)
+	case reflect.Slice:
+		l, ok := v.(types.List)
+		if !ok {
+			fail("unmarshal: expected types.List, got %T", v)
+		}
+		slice := reflect.MakeSlice(rv.Type(), int(l.Len()), int(l.Len()))
+		l.IterAll(func(cv types.Value, i uint64) {
+			unmarshalValue(cv, slice.Index(int(i)))
+		})
+		rv.Set(slice)
+	case reflect.Map:
+		m, ok := v.(types.Map)
+		if !ok {
+			fail("unmarshal: expected types.Map, got %T", v)
+		}
+		out := reflect.MakeMapWithSize(rv.Type(), int(m.Len()))
+		m.IterAll(func(k, mv types.Value) {
+			kv := reflect.New(rv.Type().Key()).Elem()
+			unmarshalValue(k, kv)
+			vv := reflect.New(rv.Type().Elem()).Elem()
+			unmarshalValue(mv, vv)
+			out.SetMapIndex(kv, vv)
+		})
+		rv.Set(out)
+	default:
+		fail("unmarshal: unsupported kind %s", rv.Kind())
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package marshal
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/types"
+)
+
+type address struct {
+	City string `noms:"city"`
+}
+
+type person struct {
+	FullName string         `noms:"full name"`
+	Age      int            `noms:"age,omitempty"`
+	Internal string         `noms:"-"`
+	Address  address        `noms:"address"`
+	Tags     []string       `noms:"tags"`
+	Scores   map[string]int `noms:"scores"`
+	ignored  string         // unexported, must never be marshaled
+}
+
+func TestMarshalUnmarshalRoundTrip(t *testing.T) {
+	in := person{
+		FullName: "Rickard Wright",
+		Age:      41,
+		Internal: "must not round-trip",
+		Address:  address{City: "Boston"},
+		Tags:     []string{"a", "b"},
+		Scores:   map[string]int{"x": 1},
+		ignored:  "must not round-trip",
+	}
+
+	s, err := Marshal(&in)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	// "full name" isn't a valid noms identifier, so it must come back
+	// escaped via types.EscapeStructField.
+	if _, ok := s.MaybeGet("full name"); ok {
+		t.Fatalf(`struct has an unescaped "full name" field`)
+	}
+
+	var out person
+	if err := Unmarshal(s, &out); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	if out.FullName != in.FullName {
+		t.Fatalf("FullName round-tripped as %q, want %q", out.FullName, in.FullName)
+	}
+	if out.Age != in.Age {
+		t.Fatalf("Age round-tripped as %d, want %d", out.Age, in.Age)
+	}
+	if out.Internal != "" {
+		t.Fatalf(`Internal round-tripped as %q, want "" (noms:"-")`, out.Internal)
+	}
+	if out.Address.City != in.Address.City {
+		t.Fatalf("Address.City round-tripped as %q, want %q", out.Address.City, in.Address.City)
+	}
+	if len(out.Tags) != 2 || out.Tags[0] != "a" || out.Tags[1] != "b" {
+		t.Fatalf("Tags round-tripped as %v, want [a b]", out.Tags)
+	}
+	if out.Scores["x"] != 1 {
+		t.Fatalf("Scores round-tripped as %v, want map[x:1]", out.Scores)
+	}
+	if out.ignored != "" {
+		t.Fatalf("unexported field should never be touched, got %q", out.ignored)
+	}
+}
+
+func TestMarshalOmitsZeroValueWithOmitempty(t *testing.T) {
+	s, err := Marshal(&person{FullName: "Rickard Wright"})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if _, ok := s.MaybeGet("age"); ok {
+		t.Fatalf("age=0 with omitempty should have been omitted, but field is present")
+	}
+}
+
+func TestUnmarshalRejectsNonPointer(t *testing.T) {
+	if err := Unmarshal(mustMarshal(t, &person{FullName: "x"}), person{}); err == nil {
+		t.Fatalf("Unmarshal into a non-pointer should return an error, got nil")
+	}
+}
+
+func TestUnmarshalRejectsPointerToNonStruct(t *testing.T) {
+	var i int
+	if err := Unmarshal(mustMarshal(t, &person{FullName: "x"}), &i); err == nil {
+		t.Fatalf("Unmarshal into *int should return an error, got nil")
+	}
+}
+
+func TestUnmarshalRejectsFieldTypeMismatch(t *testing.T) {
+	// "FullName" comes back from Marshal as a types.String, so unmarshaling
+	// it into an int-typed Go field must fail cleanly with an error
+	// instead of panicking.
+	type mismatched struct {
+		FullName int `noms:"full name"`
+	}
+
+	var out mismatched
+	err := Unmarshal(mustMarshal(t, &person{FullName: "x"}), &out)
+	if err == nil {
+		t.Fatalf("Unmarshal with a mismatched field type should return an error, got nil")
+	}
+}
+
+func mustMarshal(t *testing.T, v interface{}) types.Struct {
+	t.Helper()
+	out, err := Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	return out
+}
@@ -15,14 +15,15 @@ import (
 )
 
 var EmptyStructType = MakeStructType("", []string{}, []*Type{})
-var EmptyStruct = Struct{ValueSlice{}, EmptyStructType, &hash.Hash{}}
+var EmptyStruct = Struct{eagerFields{}, EmptyStructType, &hash.Hash{}, nil}
 
 type structData map[string]Value
 
 type Struct struct {
-	values []Value
+	values fieldValues
 	t      *Type
 	h      *hash.Hash
+	tags   []string
 }
 
 func NewStruct(name string, data structData) Struct {
@@ -41,7 +42,7 @@ func NewStruct(name string, data structData) Struct {
 		values[i] = data[fn]
 	}
 
-	return Struct{values, MakeStructType(name, fieldNames, fieldTypes), &hash.Hash{}}
+	return Struct{eagerFields(values), MakeStructType(name, fieldNames, fieldTypes), &hash.Hash{}, nil}
 }
 
 func NewStructWithType(t *Type, data ValueSlice) Struct {
@@ -51,7 +52,7 @@ func NewStructWithType(t *Type, data ValueSlice) Struct {
 		v := data[i]
 		assertSubtype(field.t, v)
 	}
-	return Struct{data, t, &hash.Hash{}}
+	return Struct{eagerFields(data), t, &hash.Hash{}, nil}
 }
 
 func (s Struct) hashPointer() *hash.Hash {
@@ -67,6 +68,12 @@ func (s Struct) Less(other Value) bool {
 	return valueLess(s, other)
 }
 
+// Hash returns s's content hash. It never varies with field tag metadata
+// (see StructTag): tags have no on-disk encoding, so decoding a chunk back
+// into a Struct always produces untagged values (NewStructWithType, which
+// every decode path uses, hard-codes nil tags), and a hash that depended on
+// tags could not survive that round trip. Callers that specifically need a
+// tag-aware digest can opt into one with TaggedHash.
 func (s Struct) Hash() hash.Hash {
 	if s.h.IsEmpty() {
 		*s.h = getHash(s)
@@ -76,12 +83,12 @@ func (s Struct) Hash() hash.Hash {
 }
 
 func (s Struct) ChildValues() []Value {
-	return s.values
+	return s.values.all()
 }
 
 func (s Struct) Chunks() (chunks []Ref) {
 	chunks = append(chunks, s.t.Chunks()...)
-	for _, v := range s.values {
+	for _, v := range s.values.all() {
 		chunks = append(chunks, v.Chunks()...)
 	}
 
@@ -101,7 +108,7 @@ func (s Struct) MaybeGet(n string) (Value, bool) {
 	if i == -1 {
 		return nil, false
 	}
-	return s.values[i], true
+	return s.values.at(i), true
 }
 
 func (s Struct) Get(n string) Value {
@@ -109,7 +116,7 @@ func (s Struct) Get(n string) Value {
 	if i == -1 {
 		d.Chk.Fail(`Struct has no field "%s"`, n)
 	}
-	return s.values[i]
+	return s.values.at(i)
 }
 
 func (s Struct) Set(n string, v Value) Struct {
@@ -118,11 +125,30 @@ func (s Struct) Set(n string, v Value) Struct {
 		d.Chk.Fail(`Struct has no field "%s"`, n)
 	}
 	assertSubtype(f.t, v)
-	values := make([]Value, len(s.values))
-	copy(values, s.values)
-	values[i] = v
 
-	return Struct{values, s.t, &hash.Hash{}}
+	return Struct{s.values.withReplaced(i, v), s.t, &hash.Hash{}, s.tags}
+}
+
+// FieldNames returns the names of s's fields, in the struct's canonical
+// (sorted) order, without forcing any field values to be decoded.
+func (s Struct) FieldNames() []string {
+	desc := s.desc()
+	names := make([]string, len(desc.fields))
+	for i, f := range desc.fields {
+		names[i] = f.name
+	}
+	return names
+}
+
+// IterFields calls cb once per field, in canonical order, decoding each
+// field's value lazily as it's visited rather than materializing every
+// value up front. IterFields stops early if cb returns true.
+func (s Struct) IterFields(cb func(name string, v Value) (stop bool)) {
+	for i, f := range s.desc().fields {
+		if cb(f.name, s.values.at(i)) {
+			return
+		}
+	}
 }
 
 func StructDiff(s1, s2 Struct) (changes []ValueChanged) {
@@ -156,6 +182,8 @@ func StructDiff(s1, s2 Struct) (changes []ValueChanged) {
 		if ok1 && ok2 {
 			if !v1.Equals(v2) {
 				changes = append(changes, ValueChanged{ChangeType: DiffChangeModified, V: String(fn)})
+			} else if s1.tagFor(fn) != s2.tagFor(fn) {
+				changes = append(changes, ValueChanged{ChangeType: DiffChangeTagModified, V: String(fn)})
 			}
 		} else if ok1 {
 			changes = append(changes, ValueChanged{ChangeType: DiffChangeRemoved, V: String(fn)})
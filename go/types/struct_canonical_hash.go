@@ -0,0 +1,50 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import (
+	"sort"
+
+	"github.com/attic-labs/noms/go/hash"
+)
+
+// CanonicalHash computes a digest over s that is stable regardless of the
+// struct's declared name, its field tag metadata (see StructTag), or the
+// order in which its fields were constructed. It sorts field names
+// lexicographically and feeds len(name)|name|valueHash for each field into
+// the module's hash function, recursing into nested Struct values via
+// CanonicalHash rather than Hash so that structurally-equivalent records
+// produced by different importers hash identically.
+func CanonicalHash(s Struct) hash.Hash {
+	fieldNames := make(sort.StringSlice, len(s.desc().fields))
+	for i, f := range s.desc().fields {
+		fieldNames[i] = f.name
+	}
+	sort.Sort(fieldNames)
+
+	var data []byte
+	for _, fn := range fieldNames {
+		v := s.Get(fn)
+
+		var vh hash.Hash
+		if nested, ok := v.(Struct); ok {
+			vh = CanonicalHash(nested)
+		} else {
+			vh = v.Hash()
+		}
+
+		data = appendLenPrefixed(data, []byte(fn))
+		data = append(data, vh[:]...)
+	}
+
+	return hash.Of(data)
+}
+
+func appendLenPrefixed(data, field []byte) []byte {
+	n := len(field)
+	data = append(data,
+		byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	return append(data, field...)
+}
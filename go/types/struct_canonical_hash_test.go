@@ -0,0 +1,51 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import "testing"
+
+func TestCanonicalHashIgnoresNameAndTags(t *testing.T) {
+	a := NewStruct("PersonA", structData{"name": String("Rickard"), "age": Number(42)})
+	b := NewStructWithTags("PersonB", structData{"name": String("Rickard"), "age": Number(42)},
+		map[string]StructTag{"name": `json:"name"`})
+
+	if a.Hash() == b.Hash() {
+		t.Fatalf("Hash() unexpectedly matched across differently-named structs")
+	}
+	if CanonicalHash(a) != CanonicalHash(b) {
+		t.Fatalf("CanonicalHash should ignore struct name and tags, but differed")
+	}
+}
+
+func TestCanonicalHashIgnoresConstructionOrder(t *testing.T) {
+	a := NewStruct("Person", structData{"name": String("Rickard"), "age": Number(42)})
+	b := NewStruct("Person", structData{"age": Number(42), "name": String("Rickard")})
+
+	if CanonicalHash(a) != CanonicalHash(b) {
+		t.Fatalf("CanonicalHash should be independent of field construction order")
+	}
+}
+
+func TestCanonicalHashDetectsValueDifference(t *testing.T) {
+	a := NewStruct("Person", structData{"name": String("Rickard")})
+	b := NewStruct("Person", structData{"name": String("Aaron")})
+
+	if CanonicalHash(a) == CanonicalHash(b) {
+		t.Fatalf("CanonicalHash should differ when field values differ")
+	}
+}
+
+func TestCanonicalHashRecursesIntoNestedStructs(t *testing.T) {
+	inner := NewStruct("Address", structData{"city": String("Boston")})
+	outerNamed := NewStruct("Person", structData{"address": inner})
+
+	innerSameShapeDifferentName := NewStructWithTags("AddressV2", structData{"city": String("Boston")},
+		map[string]StructTag{"city": `json:"city"`})
+	outerOther := NewStruct("Person", structData{"address": innerSameShapeDifferentName})
+
+	if CanonicalHash(outerNamed) != CanonicalHash(outerOther) {
+		t.Fatalf("CanonicalHash should recurse via CanonicalHash, ignoring the nested struct's name/tags too")
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import (
+	"sync"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+)
+
+// fieldValues backs Struct's field values. It exists so that a struct can be
+// built from already-decoded values (eagerFields) or from a chunk whose
+// fields are decoded one at a time on first access (lazyFields), without
+// Get/MaybeGet/IterFields needing to know which is in play.
+type fieldValues interface {
+	len() int
+	at(i int) Value
+	all() []Value
+	// withReplaced returns a fieldValues equal to the receiver except that
+	// slot i holds v. Implementations must not force-decode any slot other
+	// than i to do this.
+	withReplaced(i int, v Value) fieldValues
+}
+
+// eagerFields is a fieldValues backed by a slice of already-decoded values,
+// the common case today: every noms Value constructed in memory or read via
+// the existing (eager) decode path.
+type eagerFields ValueSlice
+
+func (f eagerFields) len() int       { return len(f) }
+func (f eagerFields) at(i int) Value { return f[i] }
+func (f eagerFields) all() []Value   { return ValueSlice(f) }
+
+func (f eagerFields) withReplaced(i int, v Value) fieldValues {
+	out := make(ValueSlice, len(f))
+	copy(out, f)
+	out[i] = v
+	return eagerFields(out)
+}
+
+// lazyFields is a fieldValues backed by the struct's raw, not-yet-decoded
+// field slots plus a decode function supplied by the chunk reader. Each slot
+// is decoded at most once, on first access, and the decoded Value is cached;
+// reading a single field of a wide struct therefore only pays the decode
+// cost for that one field rather than all of them.
+type lazyFields struct {
+	mu     sync.Mutex
+	raw    []interface{} // opaque, reader-specific undecoded field payloads
+	cache  []Value
+	decode func(raw interface{}) Value
+}
+
+// newLazyFields builds a fieldValues that decodes each of raw's slots with
+// decode the first time it's requested.
+func newLazyFields(raw []interface{}, decode func(raw interface{}) Value) fieldValues {
+	return &lazyFields{raw: raw, cache: make([]Value, len(raw)), decode: decode}
+}
+
+func (f *lazyFields) len() int { return len(f.raw) }
+
+func (f *lazyFields) at(i int) Value {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.cache[i] == nil {
+		f.cache[i] = f.decode(f.raw[i])
+	}
+	return f.cache[i]
+}
+
+func (f *lazyFields) all() []Value {
+	out := make([]Value, f.len())
+	for i := range out {
+		out[i] = f.at(i)
+	}
+	return out
+}
+
+// withReplaced copies the cache (decoded-so-far) slots but decodes nothing:
+// slots other than i that haven't been read yet stay lazy in the result.
+func (f *lazyFields) withReplaced(i int, v Value) fieldValues {
+	f.mu.Lock()
+	newCache := make([]Value, len(f.cache))
+	copy(newCache, f.cache)
+	f.mu.Unlock()
+
+	newCache[i] = v
+	return &lazyFields{raw: f.raw, cache: newCache, decode: f.decode}
+}
+
+// NewStructWithTypeAndDecoder builds a Struct whose field values are decoded
+// lazily from raw, reader-supplied payloads rather than eagerly up front.
+// decode is invoked at most once per field slot, the first time that field
+// is actually read via Get/MaybeGet/IterFields — this is the constructor the
+// chunk-reading decode path should use for structs instead of
+// NewStructWithType once it wants to avoid materializing every field of a
+// wide struct just to read one.
+func NewStructWithTypeAndDecoder(t *Type, raw []interface{}, decode func(raw interface{}) Value) Struct {
+	desc := t.Desc.(StructDesc)
+	d.Chk.True(len(raw) == len(desc.fields))
+	return Struct{newLazyFields(raw, decode), t, &hash.Hash{}, nil}
+}
@@ -0,0 +1,144 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func wideStructTypeAndRaw(n int) (*Type, []interface{}) {
+	fieldNames := make([]string, n)
+	fieldTypes := make([]*Type, n)
+	raw := make([]interface{}, n)
+	for i := 0; i < n; i++ {
+		fieldNames[i] = fmt.Sprintf("field%d", i)
+		fieldTypes[i] = NumberType
+		raw[i] = i
+	}
+	return MakeStructType("WideStruct", fieldNames, fieldTypes), raw
+}
+
+func decodeWideStructField(raw interface{}) Value {
+	return Number(float64(raw.(int)))
+}
+
+func makeWideStruct(n int, lazy bool) Struct {
+	t, raw := wideStructTypeAndRaw(n)
+	if lazy {
+		return NewStructWithTypeAndDecoder(t, raw, decodeWideStructField)
+	}
+
+	vs := make(ValueSlice, n)
+	for i, r := range raw {
+		vs[i] = decodeWideStructField(r)
+	}
+	return NewStructWithType(t, vs)
+}
+
+func TestStructWithTypeAndDecoderDecodesOnlyRequestedField(t *testing.T) {
+	typ, raw := wideStructTypeAndRaw(10)
+	decoded := map[int]bool{}
+	s := NewStructWithTypeAndDecoder(typ, raw, func(r interface{}) Value {
+		decoded[r.(int)] = true
+		return decodeWideStructField(r)
+	})
+
+	if got := s.Get("field3"); got != Number(3) {
+		t.Fatalf("Get(field3) = %v, want 3", got)
+	}
+	if len(decoded) != 1 || !decoded[3] {
+		t.Fatalf("decode called for %v, want only slot 3", decoded)
+	}
+
+	s2 := s.Set("field7", Number(700))
+	if len(decoded) != 1 {
+		t.Fatalf("Set decoded %v, want it to leave other slots untouched", decoded)
+	}
+	if got := s2.Get("field7"); got != Number(700) {
+		t.Fatalf("Get(field7) after Set = %v, want 700", got)
+	}
+	if got := s2.Get("field3"); got != Number(3) {
+		t.Fatalf("Get(field3) after unrelated Set = %v, want 3", got)
+	}
+}
+
+func TestStructFieldNamesReturnsCanonicalOrder(t *testing.T) {
+	s := NewStruct("Person", structData{
+		"name": String("Rickard"),
+		"age":  Number(42),
+		"city": String("Boston"),
+	})
+
+	if got, want := s.FieldNames(), []string{"age", "city", "name"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("FieldNames() = %v, want %v", got, want)
+	}
+}
+
+func TestStructIterFieldsVisitsAllInCanonicalOrder(t *testing.T) {
+	s := NewStruct("Person", structData{
+		"name": String("Rickard"),
+		"age":  Number(42),
+		"city": String("Boston"),
+	})
+
+	var names []string
+	var values []Value
+	s.IterFields(func(name string, v Value) bool {
+		names = append(names, name)
+		values = append(values, v)
+		return false
+	})
+
+	wantNames := []string{"age", "city", "name"}
+	wantValues := []Value{Number(42), String("Boston"), String("Rickard")}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Fatalf("IterFields visited names %v, want %v", names, wantNames)
+	}
+	if !reflect.DeepEqual(values, wantValues) {
+		t.Fatalf("IterFields visited values %v, want %v", values, wantValues)
+	}
+}
+
+func TestStructIterFieldsStopsWithoutDecodingRemainingFields(t *testing.T) {
+	typ, raw := wideStructTypeAndRaw(10)
+	decoded := map[int]bool{}
+	s := NewStructWithTypeAndDecoder(typ, raw, func(r interface{}) Value {
+		decoded[r.(int)] = true
+		return decodeWideStructField(r)
+	})
+
+	var seen []string
+	s.IterFields(func(name string, v Value) bool {
+		seen = append(seen, name)
+		return name == "field2"
+	})
+
+	if want := []string{"field0", "field1", "field2"}; !reflect.DeepEqual(seen, want) {
+		t.Fatalf("IterFields visited %v before stopping, want %v", seen, want)
+	}
+	if len(decoded) != 3 || !decoded[0] || !decoded[1] || !decoded[2] {
+		t.Fatalf("decode called for %v, want only slots 0-2", decoded)
+	}
+}
+
+// BenchmarkStructGetOneFieldEager materializes all 1000 fields up front,
+// then reads a single one.
+func BenchmarkStructGetOneFieldEager(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		s := makeWideStruct(1000, false)
+		s.Get("field500")
+	}
+}
+
+// BenchmarkStructGetOneFieldLazy defers decoding and only pays for the one
+// field actually read.
+func BenchmarkStructGetOneFieldLazy(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		s := makeWideStruct(1000, true)
+		s.Get("field500")
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import "fmt"
+
+// PatchOp identifies the kind of change a PatchOperation represents.
+type PatchOp uint8
+
+const (
+	PatchAdd PatchOp = iota
+	PatchRemove
+	PatchModify
+)
+
+// PatchOperation is a single field-level change produced by StructPatch and
+// consumed by ApplyPatch.
+type PatchOperation struct {
+	Op    PatchOp
+	Field string
+	Value Value
+}
+
+// Patch is an ordered, serializable change set between two structs.
+type Patch []PatchOperation
+
+// StructPatch computes the field-level changes needed to turn base into
+// target, expressed as a Patch. It builds on StructDiff, attaching the
+// target's (or, for removals, no) value to each changed field.
+func StructPatch(base, target Struct) Patch {
+	var patch Patch
+	for _, c := range StructDiff(base, target) {
+		fn := string(c.V.(String))
+		switch c.ChangeType {
+		case DiffChangeAdded:
+			patch = append(patch, PatchOperation{Op: PatchAdd, Field: fn, Value: target.Get(fn)})
+		case DiffChangeRemoved:
+			patch = append(patch, PatchOperation{Op: PatchRemove, Field: fn})
+		case DiffChangeModified:
+			patch = append(patch, PatchOperation{Op: PatchModify, Field: fn, Value: target.Get(fn)})
+		}
+	}
+	return patch
+}
+
+// ApplyPatch applies p to s, returning the resulting Struct. It returns an
+// error if p removes or modifies a field that isn't present on s.
+func ApplyPatch(s Struct, p Patch) (Struct, error) {
+	data := structData{}
+	for _, fn := range s.desc().fields {
+		data[fn.name] = s.Get(fn.name)
+	}
+
+	for _, op := range p {
+		switch op.Op {
+		case PatchAdd:
+			data[op.Field] = op.Value
+		case PatchModify:
+			if _, ok := data[op.Field]; !ok {
+				return Struct{}, fmt.Errorf("ApplyPatch: field %q not present", op.Field)
+			}
+			data[op.Field] = op.Value
+		case PatchRemove:
+			if _, ok := data[op.Field]; !ok {
+				return Struct{}, fmt.Errorf("ApplyPatch: field %q not present", op.Field)
+			}
+			delete(data, op.Field)
+		default:
+			return Struct{}, fmt.Errorf("ApplyPatch: unknown op %d for field %q", op.Op, op.Field)
+		}
+	}
+
+	return NewStruct(s.desc().Name, data), nil
+}
+
+// Conflict describes a field that base, left, and right all disagree on
+// during a three-way MergeStruct. Field is a dotted path (e.g.
+// "address.city") from the root struct down to the conflicting field, so
+// that conflicts on same-named fields in unrelated nested structs (e.g.
+// "address.city" vs "employer.city") remain distinguishable.
+type Conflict struct {
+	Field string
+	Base  Value // nil if the field didn't exist in base
+	Left  Value
+	Right Value
+}
+
+// ConflictResolver lets callers plug in a policy (prefer-left, prefer-right,
+// custom) for resolving a Conflict. It returns the resolved value and
+// whether it resolved the conflict; returning false leaves the conflict in
+// the []Conflict result returned by MergeStruct.
+type ConflictResolver interface {
+	Resolve(c Conflict) (resolved Value, ok bool)
+}
+
+// PreferLeft resolves every conflict in favor of the left struct's value.
+var PreferLeft ConflictResolver = resolverFunc(func(c Conflict) (Value, bool) { return c.Left, true })
+
+// PreferRight resolves every conflict in favor of the right struct's value.
+var PreferRight ConflictResolver = resolverFunc(func(c Conflict) (Value, bool) { return c.Right, true })
+
+type resolverFunc func(c Conflict) (Value, bool)
+
+func (f resolverFunc) Resolve(c Conflict) (Value, bool) { return f(c) }
+
+// MergeStruct performs a three-way merge of left and right against their
+// common ancestor base, applying non-conflicting changes from both sides.
+// When both sides modify the same field to different values, the conflict
+// is reported rather than guessed at; use MergeStructResolving to plug in a
+// ConflictResolver policy instead. Fields that are themselves nested Structs
+// are merged recursively, so a change to one field of a nested struct on
+// the left and a different field on the right merges cleanly instead of
+// conflicting on the whole nested value.
+func MergeStruct(base, left, right Struct) (Struct, []Conflict, error) {
+	return MergeStructResolving(base, left, right, nil)
+}
+
+// MergeStructResolving is like MergeStruct, but consults resolver for each
+// field both sides changed to different values before giving up and
+// reporting it as a Conflict.
+func MergeStructResolving(base, left, right Struct, resolver ConflictResolver) (Struct, []Conflict, error) {
+	return mergeStructResolving(base, left, right, resolver, "")
+}
+
+func mergeStructResolving(base, left, right Struct, resolver ConflictResolver, path string) (Struct, []Conflict, error) {
+	fieldNames := map[string]struct{}{}
+	for _, s := range []Struct{base, left, right} {
+		for _, f := range s.desc().fields {
+			fieldNames[f.name] = struct{}{}
+		}
+	}
+
+	data := structData{}
+	var conflicts []Conflict
+
+	for fn := range fieldNames {
+		bv, bok := base.MaybeGet(fn)
+		lv, lok := left.MaybeGet(fn)
+		rv, rok := right.MaybeGet(fn)
+
+		leftChanged := !valueEqual(bv, bok, lv, lok)
+		rightChanged := !valueEqual(bv, bok, rv, rok)
+
+		switch {
+		case !leftChanged && !rightChanged:
+			if bok {
+				data[fn] = bv
+			}
+		case leftChanged && !rightChanged:
+			if lok {
+				data[fn] = lv
+			}
+		case !leftChanged && rightChanged:
+			if rok {
+				data[fn] = rv
+			}
+		case valueEqual(lv, lok, rv, rok):
+			if lok {
+				data[fn] = lv
+			}
+		case bok && lok && rok:
+			if lnested, ok := lv.(Struct); ok {
+				if rnested, ok2 := rv.(Struct); ok2 {
+					if bnested, ok3 := bv.(Struct); ok3 {
+						merged, nested, err := mergeStructResolving(bnested, lnested, rnested, resolver, joinPath(path, fn))
+						if err != nil {
+							return Struct{}, nil, err
+						}
+						data[fn] = merged
+						conflicts = append(conflicts, nested...)
+						continue
+					}
+				}
+			}
+			conflicts = append(conflicts, resolveOrConflict(joinPath(path, fn), bv, lv, rv, resolver, data, fn)...)
+		default:
+			conflicts = append(conflicts, resolveOrConflict(joinPath(path, fn), bv, lv, rv, resolver, data, fn)...)
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return Struct{}, conflicts, nil
+	}
+
+	name := base.desc().Name
+	if name == "" {
+		name = left.desc().Name
+	}
+	return NewStruct(name, data), nil, nil
+}
+
+// resolveOrConflict builds a Conflict rooted at path (the dotted path from
+// the merge's top-level struct), consults resolver, and on success stores
+// the resolution in data under the unqualified field name fn.
+func resolveOrConflict(path string, bv, lv, rv Value, resolver ConflictResolver, data structData, fn string) []Conflict {
+	c := Conflict{Field: path, Base: bv, Left: lv, Right: rv}
+	if resolver != nil {
+		if resolved, ok := resolver.Resolve(c); ok {
+			data[fn] = resolved
+			return nil
+		}
+	}
+	return []Conflict{c}
+}
+
+// joinPath appends field fn to the dotted conflict path so far.
+func joinPath(path, fn string) string {
+	if path == "" {
+		return fn
+	}
+	return path + "." + fn
+}
+
+func valueEqual(v1 Value, ok1 bool, v2 Value, ok2 bool) bool {
+	if ok1 != ok2 {
+		return false
+	}
+	if !ok1 {
+		return true
+	}
+	return v1.Equals(v2)
+}
@@ -0,0 +1,114 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import "testing"
+
+func TestStructPatchApplyRoundTrip(t *testing.T) {
+	base := NewStruct("Person", structData{"name": String("Rickard"), "age": Number(41)})
+	target := NewStruct("Person", structData{"name": String("Rickard"), "city": String("Boston")})
+
+	patch := StructPatch(base, target)
+	got, err := ApplyPatch(base, patch)
+	if err != nil {
+		t.Fatalf("ApplyPatch: %v", err)
+	}
+	if !got.Equals(target) {
+		t.Fatalf("ApplyPatch(base, StructPatch(base, target)) != target")
+	}
+}
+
+func TestApplyPatchRemoveMissingFieldErrors(t *testing.T) {
+	base := NewStruct("Person", structData{"name": String("Rickard")})
+	patch := Patch{{Op: PatchRemove, Field: "age"}}
+
+	if _, err := ApplyPatch(base, patch); err == nil {
+		t.Fatalf("ApplyPatch removing a non-existent field should error, got nil")
+	}
+}
+
+func TestMergeStructNoConflictAppliesBothSidesChanges(t *testing.T) {
+	base := NewStruct("Person", structData{"name": String("Rickard"), "age": Number(41)})
+	left := NewStruct("Person", structData{"name": String("Rickard"), "age": Number(42)})
+	right := NewStruct("Person", structData{"name": String("Aaron"), "age": Number(41)})
+
+	merged, conflicts, err := MergeStruct(base, left, right)
+	if err != nil {
+		t.Fatalf("MergeStruct: %v", err)
+	}
+	if len(conflicts) != 0 {
+		t.Fatalf("expected no conflicts, got %v", conflicts)
+	}
+	if got := merged.Get("name"); got != String("Aaron") {
+		t.Fatalf(`merged "name" = %v, want "Aaron"`, got)
+	}
+	if got := merged.Get("age"); got != Number(42) {
+		t.Fatalf(`merged "age" = %v, want 42`, got)
+	}
+}
+
+func TestMergeStructNestedConflictsGetDottedPaths(t *testing.T) {
+	baseAddr := NewStruct("Address", structData{"city": String("Boston")})
+	leftAddr := NewStruct("Address", structData{"city": String("Cambridge")})
+	rightAddr := NewStruct("Address", structData{"city": String("Somerville")})
+
+	base := NewStruct("Person", structData{"address": baseAddr, "employer": baseAddr})
+	left := NewStruct("Person", structData{"address": leftAddr, "employer": baseAddr})
+	right := NewStruct("Person", structData{"address": rightAddr, "employer": baseAddr})
+
+	_, conflicts, err := MergeStruct(base, left, right)
+	if err != nil {
+		t.Fatalf("MergeStruct: %v", err)
+	}
+	if len(conflicts) != 1 {
+		t.Fatalf("expected exactly one conflict, got %v", conflicts)
+	}
+	if conflicts[0].Field != "address.city" {
+		t.Fatalf(`conflict field = %q, want "address.city"`, conflicts[0].Field)
+	}
+}
+
+func TestMergeStructDistinguishesSameNameNestedConflicts(t *testing.T) {
+	baseAddr := NewStruct("Address", structData{"city": String("Boston")})
+	leftAddr := NewStruct("Address", structData{"city": String("Cambridge")})
+	rightAddr := NewStruct("Address", structData{"city": String("Somerville")})
+
+	base := NewStruct("Person", structData{"address": baseAddr, "employer": baseAddr})
+	left := NewStruct("Person", structData{"address": leftAddr, "employer": leftAddr})
+	right := NewStruct("Person", structData{"address": rightAddr, "employer": rightAddr})
+
+	_, conflicts, err := MergeStruct(base, left, right)
+	if err != nil {
+		t.Fatalf("MergeStruct: %v", err)
+	}
+	if len(conflicts) != 2 {
+		t.Fatalf("expected two distinct conflicts, got %v", conflicts)
+	}
+
+	fields := map[string]bool{}
+	for _, c := range conflicts {
+		fields[c.Field] = true
+	}
+	if !fields["address.city"] || !fields["employer.city"] {
+		t.Fatalf("expected conflicts on both address.city and employer.city, got %v", conflicts)
+	}
+}
+
+func TestMergeStructResolvingAppliesPreferLeft(t *testing.T) {
+	base := NewStruct("Person", structData{"name": String("Rickard")})
+	left := NewStruct("Person", structData{"name": String("Left")})
+	right := NewStruct("Person", structData{"name": String("Right")})
+
+	merged, conflicts, err := MergeStructResolving(base, left, right, PreferLeft)
+	if err != nil {
+		t.Fatalf("MergeStructResolving: %v", err)
+	}
+	if len(conflicts) != 0 {
+		t.Fatalf("expected PreferLeft to resolve the conflict, got %v", conflicts)
+	}
+	if got := merged.Get("name"); got != String("Left") {
+		t.Fatalf(`merged "name" = %v, want "Left"`, got)
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/attic-labs/noms/go/hash"
+)
+
+// StructTag holds the raw `key:"value" key2:"value2"` metadata attached to a
+// struct field, using the same convention as Go's own struct tags. It is
+// deliberately namespaced so unrelated tools (JSON export, GraphQL schema
+// gen, validation, indexing hints, ...) can each stake out a key without
+// colliding with one another.
+type StructTag string
+
+// Get returns the value associated with key in the tag string, or the empty
+// string if key isn't present.
+func (tag StructTag) Get(key string) string {
+	v, _ := tag.Lookup(key)
+	return v
+}
+
+// Lookup returns the value associated with key in the tag string, along with
+// whether the key was present at all. This mirrors reflect.StructTag.Lookup.
+func (tag StructTag) Lookup(key string) (value string, ok bool) {
+	for tag != "" {
+		tag = StructTag(strings.TrimLeft(string(tag), " \t"))
+		if tag == "" {
+			break
+		}
+
+		i := 0
+		for i < len(tag) && tag[i] > ' ' && tag[i] != ':' && tag[i] != '"' && tag[i] != 0x7f {
+			i++
+		}
+		if i == 0 || i+1 >= len(tag) || tag[i] != ':' || tag[i+1] != '"' {
+			break
+		}
+		name := string(tag[:i])
+		tag = tag[i+1:]
+
+		i = 1
+		for i < len(tag) && tag[i] != '"' {
+			if tag[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(tag) {
+			break
+		}
+		qvalue := string(tag[:i+1])
+		tag = tag[i+1:]
+
+		if key == name {
+			value, err := strconv.Unquote(qvalue)
+			if err != nil {
+				break
+			}
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// DiffChangeTagModified reports that a field's value was unchanged but its
+// tag metadata differs between the two structs being diffed.
+const DiffChangeTagModified DiffChangeType = 100
+
+// StructField describes a single field when building a struct type via
+// MakeStructTypeWithTags: its name, its value type, and the raw tag string
+// attached to it (may be empty).
+type StructField struct {
+	Name string
+	Type *Type
+	Tag  string
+}
+
+// MakeStructTypeWithTags is like MakeStructType, but takes fields that may
+// each carry tag metadata. It returns the *Type alongside a name->tag map
+// ready to pass to NewStructWithTags.
+//
+// Deviation from a tag-inclusive default: tags are descriptive metadata
+// only, with no on-disk encoding of their own, so they cannot be folded into
+// Struct.Hash() by default without breaking the invariant that decoding a
+// chunk back into a Struct reproduces its original hash (every decode path
+// goes through NewStructWithType, which always builds an untagged Struct).
+// Neither Hash() nor CanonicalHash are affected by tags; use TaggedHash for
+// the tag-aware digest instead.
+func MakeStructTypeWithTags(name string, fields []StructField) (*Type, map[string]StructTag) {
+	fieldNames := make([]string, len(fields))
+	fieldTypes := make([]*Type, len(fields))
+	tags := make(map[string]StructTag, len(fields))
+	for i, f := range fields {
+		fieldNames[i] = f.Name
+		fieldTypes[i] = f.Type
+		if f.Tag != "" {
+			tags[f.Name] = StructTag(f.Tag)
+		}
+	}
+	return MakeStructType(name, fieldNames, fieldTypes), tags
+}
+
+// NewStructWithTags is like NewStruct, but additionally attaches tag
+// metadata to each named field. Tags are carried only on the in-memory
+// Struct value (see tagFor) and, like MakeStructTypeWithTags, never affect
+// Hash() or CanonicalHash; use TaggedHash for a digest that does vary with
+// tags. Fields absent from tags simply have no tag.
+func NewStructWithTags(name string, data structData, tags map[string]StructTag) Struct {
+	s := NewStruct(name, data)
+	if len(tags) == 0 {
+		return s
+	}
+
+	fieldNames := make(sort.StringSlice, 0, len(data))
+	for fn := range data {
+		fieldNames = append(fieldNames, fn)
+	}
+	sort.Sort(fieldNames)
+
+	tagValues := make([]string, len(fieldNames))
+	for i, fn := range fieldNames {
+		tagValues[i] = string(tags[fn])
+	}
+	s.tags = tagValues
+	return s
+}
+
+// TaggedHash computes a digest over s that, unlike Hash() and CanonicalHash,
+// does fold in field tag metadata (see StructTag): two structs with the same
+// fields and values but different tags produce different TaggedHashes. This
+// is the opt-in tag-aware identity that request #chunk0-1 asked for as the
+// default; it can't be the default because tags have no on-disk encoding, so
+// a chunk decoded back via NewStructWithType always comes back untagged and
+// could never reproduce a tag-folded hash. Callers that control both sides
+// of a comparison in memory (never across a chunk round trip) can use
+// TaggedHash to distinguish otherwise-identical structs by their tags.
+func (s Struct) TaggedHash() hash.Hash {
+	fieldNames := make(sort.StringSlice, len(s.desc().fields))
+	for i, f := range s.desc().fields {
+		fieldNames[i] = f.name
+	}
+	sort.Sort(fieldNames)
+
+	data := s.Hash()
+	buf := make([]byte, 0, len(data)+len(s.tags)*8)
+	buf = append(buf, data[:]...)
+	for _, fn := range fieldNames {
+		buf = appendLenPrefixed(buf, []byte(fn))
+		buf = appendLenPrefixed(buf, []byte(s.tagFor(fn)))
+	}
+
+	return hash.Of(buf)
+}
+
+// tagFor returns the raw tag string for field fn, or the empty string if fn
+// has no tag (or doesn't exist).
+func (s Struct) tagFor(fn string) string {
+	if len(s.tags) == 0 {
+		return ""
+	}
+	_, i := s.desc().findField(fn)
+	if i == -1 || i >= len(s.tags) {
+		return ""
+	}
+	return s.tags[i]
+}
+
+// Tag returns the tag metadata attached to field fn.
+func (s Struct) Tag(fn string) StructTag {
+	return StructTag(s.tagFor(fn))
+}
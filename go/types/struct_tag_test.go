@@ -0,0 +1,106 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import "testing"
+
+func TestStructTagLookup(t *testing.T) {
+	tag := StructTag(`json:"name,omitempty" index:"unique"`)
+
+	if v, ok := tag.Lookup("json"); !ok || v != "name,omitempty" {
+		t.Fatalf(`Lookup("json") = %q, %v; want "name,omitempty", true`, v, ok)
+	}
+	if v := tag.Get("index"); v != "unique" {
+		t.Fatalf(`Get("index") = %q; want "unique"`, v)
+	}
+	if _, ok := tag.Lookup("missing"); ok {
+		t.Fatalf(`Lookup("missing") reported ok=true, want false`)
+	}
+}
+
+func TestNewStructWithTagsRoundTrip(t *testing.T) {
+	s := NewStructWithTags("Person", structData{
+		"name": String("Rickard"),
+		"age":  Number(42),
+	}, map[string]StructTag{
+		"name": `json:"name"`,
+	})
+
+	if got := s.Tag("name"); got != `json:"name"` {
+		t.Fatalf(`Tag("name") = %q, want json:"name"`, got)
+	}
+	if got := s.Tag("age"); got != "" {
+		t.Fatalf(`Tag("age") = %q, want ""`, got)
+	}
+	if got := s.Tag("nonexistent"); got != "" {
+		t.Fatalf(`Tag("nonexistent") = %q, want ""`, got)
+	}
+}
+
+func TestMakeStructTypeWithTagsReturnsTagMap(t *testing.T) {
+	_, tags := MakeStructTypeWithTags("Person", []StructField{
+		{Name: "name", Type: StringType, Tag: `json:"name"`},
+		{Name: "age", Type: NumberType},
+	})
+
+	if tags["name"] != `json:"name"` {
+		t.Fatalf(`tags["name"] = %q, want json:"name"`, tags["name"])
+	}
+	if _, ok := tags["age"]; ok {
+		t.Fatalf(`tags["age"] present, want absent since age has no tag`)
+	}
+}
+
+// Tags must never affect Struct.Hash(): they have no on-disk encoding, so a
+// hash that depended on them couldn't survive a decode round trip through
+// the chunk store (NewStructWithType, which any such path uses, always
+// builds a struct with no tags at all).
+func TestStructHashIsUnaffectedByTags(t *testing.T) {
+	untagged := NewStruct("Person", structData{"name": String("Rickard")})
+	tagged := NewStructWithTags("Person", structData{"name": String("Rickard")}, map[string]StructTag{
+		"name": `json:"name"`,
+	})
+
+	if untagged.Hash() != tagged.Hash() {
+		t.Fatalf("Hash() differed between an untagged and a tagged struct with identical data")
+	}
+}
+
+// TaggedHash is the opt-in counterpart to TestStructHashIsUnaffectedByTags:
+// callers that do want tags to participate in identity can reach for it
+// instead of Hash().
+func TestStructTaggedHashVariesWithTags(t *testing.T) {
+	untagged := NewStruct("Person", structData{"name": String("Rickard")})
+	tagged := NewStructWithTags("Person", structData{"name": String("Rickard")}, map[string]StructTag{
+		"name": `json:"name"`,
+	})
+	retagged := NewStructWithTags("Person", structData{"name": String("Rickard")}, map[string]StructTag{
+		"name": `json:"full_name"`,
+	})
+
+	if untagged.TaggedHash() == tagged.TaggedHash() {
+		t.Fatalf("TaggedHash() matched between an untagged and a tagged struct with identical data")
+	}
+	if tagged.TaggedHash() == retagged.TaggedHash() {
+		t.Fatalf("TaggedHash() matched between two structs with different tags")
+	}
+	if tagged.TaggedHash() != tagged.TaggedHash() {
+		t.Fatalf("TaggedHash() was not stable across repeated calls on the same struct")
+	}
+}
+
+func TestStructDiffReportsTagOnlyChange(t *testing.T) {
+	s1 := NewStructWithTags("Person", structData{"name": String("Rickard")}, map[string]StructTag{
+		"name": `json:"name"`,
+	})
+	s2 := NewStructWithTags("Person", structData{"name": String("Rickard")}, map[string]StructTag{
+		"name": `json:"full_name"`,
+	})
+
+	changes := StructDiff(s1, s2)
+	if len(changes) != 1 || changes[0].ChangeType != DiffChangeTagModified {
+		t.Fatalf("StructDiff(s1, s2) = %v, want a single DiffChangeTagModified change", changes)
+	}
+}